@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/mohammedzee1000/kedge/pkg"
+)
+
+var convertViper = viper.New()
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "convert kedge app files into Kubernetes manifests",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return pkg.Convert(convertViper, cmd)
+	},
+}
+
+func init() {
+	flags := convertCmd.Flags()
+	flags.StringSlice("files", []string{}, "comma separated list of kedge app files to convert")
+	flags.Bool("apply", false, "apply the generated objects to a cluster instead of printing them")
+	flags.String("kubeconfig", "", "path to a kubeconfig file, used with --apply")
+	flags.String("namespace", "", "namespace stamped on generated objects, and applied into with --apply")
+	flags.Int("timeout", 120, "seconds to wait for applied objects to become ready, used with --apply")
+	flags.String("out-dir", "", "write one file per object into this directory instead of stdout")
+	flags.String("out-file", "", "write all objects into this single file instead of stdout")
+
+	if err := convertViper.BindPFlags(flags); err != nil {
+		panic(err)
+	}
+
+	rootCmd.AddCommand(convertCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/runtime"
+
+	"github.com/mohammedzee1000/kedge/pkg"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [manifest files...]",
+	Short: "generate a kedge app file from existing Kubernetes manifests",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("generate needs at least one manifest file")
+		}
+
+		var objs []runtime.Object
+		for _, file := range args {
+			data, err := pkg.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			for _, doc := range strings.Split(string(data), "---") {
+				if strings.TrimSpace(doc) == "" {
+					continue
+				}
+
+				obj, _, err := api.Codecs.UniversalDeserializer().Decode([]byte(doc), nil, nil)
+				if err != nil {
+					return errors.Wrapf(err, "could not decode manifest in %s", file)
+				}
+				objs = append(objs, obj)
+			}
+		}
+
+		app, err := pkg.Generate(objs)
+		if err != nil {
+			return errors.Wrap(err, "could not generate app")
+		}
+
+		out, err := yaml.Marshal(app)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal app")
+		}
+
+		_, err = cmd.OutOrStdout().Write(out)
+		return errors.Wrap(err, "could not write app")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+}
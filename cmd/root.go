@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "kedge",
+	Short: "kedge simplifies writing and deploying Kubernetes manifests",
+}
+
+// Execute runs the root command; main() just calls this and reports the
+// resulting error.
+func Execute() error {
+	return rootCmd.Execute()
+}
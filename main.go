@@ -0,0 +1,13 @@
+package main
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/mohammedzee1000/kedge/cmd"
+)
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
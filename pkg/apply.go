@@ -0,0 +1,412 @@
+package pkg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	apierrors "k8s.io/client-go/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+	apps_v1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/ghodss/yaml"
+	log "github.com/Sirupsen/logrus"
+)
+
+// ObjectStatus records the outcome of writing a single object so callers
+// (e.g. a CI job) can inspect a structured summary instead of scraping logs.
+type ObjectStatus struct {
+	Kind    string
+	Name    string
+	Status  string
+	Message string
+}
+
+// Writer is how Convert hands off the objects it builds. The default is a
+// localWriter (print/write YAML); --apply switches to applyWriter, which
+// pushes the objects to a live cluster instead.
+type Writer interface {
+	Write(objects []runtime.Object) ([]ObjectStatus, error)
+	Close() error
+}
+
+type localWriter struct {
+	emitter Emitter
+}
+
+// NewLocalWriter returns the Writer used when --apply is not given: it
+// marshals each object to YAML and hands it to emitter.
+func NewLocalWriter(emitter Emitter) Writer {
+	return &localWriter{emitter: emitter}
+}
+
+func (w *localWriter) Write(objects []runtime.Object) ([]ObjectStatus, error) {
+	var statuses []ObjectStatus
+	for _, o := range objects {
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return statuses, errors.Wrap(err, "failed to marshal object")
+		}
+
+		if err := w.emitter.Emit(o, data); err != nil {
+			return statuses, errors.Wrap(err, "failed to emit object")
+		}
+
+		statuses = append(statuses, ObjectStatus{
+			Kind:   o.GetObjectKind().GroupVersionKind().Kind,
+			Status: "Written",
+		})
+	}
+	return statuses, nil
+}
+
+func (w *localWriter) Close() error {
+	return w.emitter.Close()
+}
+
+type applyWriter struct {
+	client    kubernetes.Interface
+	namespace string
+	timeout   time.Duration
+}
+
+// NewApplyWriter builds a Writer that creates/updates objects in namespace
+// on the cluster described by kubeconfig, auto-creating the namespace if it
+// does not already exist, then waits up to timeout for each Deployment,
+// StatefulSet and PersistentVolumeClaim to become ready.
+func NewApplyWriter(kubeconfig, namespace string, timeout time.Duration) (Writer, error) {
+	if namespace == "" {
+		return nil, errors.New("--namespace is required with --apply")
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build kube client config")
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create kube client")
+	}
+
+	return &applyWriter{client: client, namespace: namespace, timeout: timeout}, nil
+}
+
+func ensureNamespaceExists(client kubernetes.Interface, namespace string) error {
+	_, err := client.CoreV1().Namespaces().Get(namespace, v1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "could not get namespace")
+	}
+
+	_, err = client.CoreV1().Namespaces().Create(&v1.Namespace{
+		ObjectMeta: v1.ObjectMeta{Name: namespace},
+	})
+	return errors.Wrap(err, "could not create namespace")
+}
+
+// Close is a no-op: the kube client has no connection to tear down.
+func (w *applyWriter) Close() error {
+	return nil
+}
+
+func (w *applyWriter) Write(objects []runtime.Object) ([]ObjectStatus, error) {
+	if err := ensureNamespaceExists(w.client, w.namespace); err != nil {
+		return nil, err
+	}
+
+	var statuses []ObjectStatus
+	for _, o := range objects {
+		status, err := w.applyObject(o)
+		statuses = append(statuses, status)
+		if err != nil {
+			return statuses, err
+		}
+	}
+	return statuses, nil
+}
+
+// createOrUpdate tries create first, same as before; it only falls back to
+// update when create fails because the object already exists, fetching the
+// live ResourceVersion first since the API server rejects updates without
+// one. Any other create error (auth, quota, bad namespace, ...) is returned
+// as-is instead of being masked by a doomed update attempt.
+func createOrUpdate(create func() error, get func() (resourceVersion string, err error), update func(resourceVersion string) error) error {
+	err := create()
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	rv, err := get()
+	if err != nil {
+		return err
+	}
+	return update(rv)
+}
+
+func (w *applyWriter) applyObject(o runtime.Object) (ObjectStatus, error) {
+	switch obj := o.(type) {
+	case *ext_v1beta1.Deployment:
+		client := w.client.ExtensionsV1beta1().Deployments(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "Deployment", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply deployment")
+		}
+		return w.waitForDeployment(obj.Name)
+	case *v1.Service:
+		client := w.client.CoreV1().Services(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "Service", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply service")
+		}
+		return ObjectStatus{Kind: "Service", Name: obj.Name, Status: "Applied"}, nil
+	case *ext_v1beta1.Ingress:
+		client := w.client.ExtensionsV1beta1().Ingresses(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "Ingress", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply ingress")
+		}
+		return ObjectStatus{Kind: "Ingress", Name: obj.Name, Status: "Applied"}, nil
+	case *v1.PersistentVolumeClaim:
+		client := w.client.CoreV1().PersistentVolumeClaims(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "PersistentVolumeClaim", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply pvc")
+		}
+		return w.waitForPVC(obj.Name)
+	case *apps_v1beta1.StatefulSet:
+		client := w.client.AppsV1beta1().StatefulSets(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "StatefulSet", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply statefulset")
+		}
+		return w.waitForStatefulSet(obj.Name)
+	case *ext_v1beta1.DaemonSet:
+		client := w.client.ExtensionsV1beta1().DaemonSets(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "DaemonSet", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply daemonset")
+		}
+		return ObjectStatus{Kind: "DaemonSet", Name: obj.Name, Status: "Applied"}, nil
+	case *v1.ConfigMap:
+		client := w.client.CoreV1().ConfigMaps(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "ConfigMap", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply configmap")
+		}
+		return ObjectStatus{Kind: "ConfigMap", Name: obj.Name, Status: "Applied"}, nil
+	case *v1.Secret:
+		client := w.client.CoreV1().Secrets(w.namespace)
+		err := createOrUpdate(
+			func() error { _, err := client.Create(obj); return err },
+			func() (string, error) {
+				existing, err := client.Get(obj.Name, v1.GetOptions{})
+				if err != nil {
+					return "", err
+				}
+				return existing.ResourceVersion, nil
+			},
+			func(rv string) error {
+				obj.ResourceVersion = rv
+				_, err := client.Update(obj)
+				return err
+			},
+		)
+		if err != nil {
+			return ObjectStatus{Kind: "Secret", Name: obj.Name, Status: "Failed"}, errors.Wrap(err, "could not apply secret")
+		}
+		return ObjectStatus{Kind: "Secret", Name: obj.Name, Status: "Applied"}, nil
+	default:
+		return ObjectStatus{Kind: fmt.Sprintf("%T", o), Status: "Skipped", Message: "unknown object type, not applied"}, nil
+	}
+}
+
+func (w *applyWriter) waitForDeployment(name string) (ObjectStatus, error) {
+	client := w.client.ExtensionsV1beta1().Deployments(w.namespace)
+	deadline := time.Now().Add(w.timeout)
+
+	for {
+		d, err := client.Get(name, v1.GetOptions{})
+		if err != nil {
+			return ObjectStatus{Kind: "Deployment", Name: name, Status: "Failed"}, errors.Wrap(err, "could not get deployment status")
+		}
+
+		// Replicas is optional on App, same as createDeployment leaves it;
+		// the API server defaults a nil value to 1
+		wantReplicas := int32(1)
+		if d.Spec.Replicas != nil {
+			wantReplicas = *d.Spec.Replicas
+		}
+
+		if d.Status.AvailableReplicas == wantReplicas {
+			return ObjectStatus{Kind: "Deployment", Name: name, Status: "Ready"}, nil
+		}
+
+		if time.Now().After(deadline) {
+			return ObjectStatus{Kind: "Deployment", Name: name, Status: "Timeout", Message: fmt.Sprintf("%d/%d replicas available", d.Status.AvailableReplicas, wantReplicas)}, nil
+		}
+
+		log.Debugf("waiting for deployment %s: %d/%d replicas available", name, d.Status.AvailableReplicas, wantReplicas)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (w *applyWriter) waitForStatefulSet(name string) (ObjectStatus, error) {
+	client := w.client.AppsV1beta1().StatefulSets(w.namespace)
+	deadline := time.Now().Add(w.timeout)
+
+	for {
+		s, err := client.Get(name, v1.GetOptions{})
+		if err != nil {
+			return ObjectStatus{Kind: "StatefulSet", Name: name, Status: "Failed"}, errors.Wrap(err, "could not get statefulset status")
+		}
+
+		// Replicas is optional on App, same as createStatefulSet leaves it;
+		// the API server defaults a nil value to 1
+		wantReplicas := int32(1)
+		if s.Spec.Replicas != nil {
+			wantReplicas = *s.Spec.Replicas
+		}
+
+		if s.Status.ReadyReplicas == wantReplicas {
+			return ObjectStatus{Kind: "StatefulSet", Name: name, Status: "Ready"}, nil
+		}
+
+		if time.Now().After(deadline) {
+			return ObjectStatus{Kind: "StatefulSet", Name: name, Status: "Timeout", Message: fmt.Sprintf("%d/%d replicas ready", s.Status.ReadyReplicas, wantReplicas)}, nil
+		}
+
+		log.Debugf("waiting for statefulset %s: %d/%d replicas ready", name, s.Status.ReadyReplicas, wantReplicas)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (w *applyWriter) waitForPVC(name string) (ObjectStatus, error) {
+	client := w.client.CoreV1().PersistentVolumeClaims(w.namespace)
+	deadline := time.Now().Add(w.timeout)
+
+	for {
+		p, err := client.Get(name, v1.GetOptions{})
+		if err != nil {
+			return ObjectStatus{Kind: "PersistentVolumeClaim", Name: name, Status: "Failed"}, errors.Wrap(err, "could not get pvc status")
+		}
+
+		if p.Status.Phase == v1.ClaimBound {
+			return ObjectStatus{Kind: "PersistentVolumeClaim", Name: name, Status: "Bound"}, nil
+		}
+
+		if time.Now().After(deadline) {
+			return ObjectStatus{Kind: "PersistentVolumeClaim", Name: name, Status: "Timeout", Message: string(p.Status.Phase)}, nil
+		}
+
+		log.Debugf("waiting for pvc %s: phase is %s", name, p.Status.Phase)
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	api_v1 "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// ConfigMap lets an App carry its configuration inline instead of forcing
+// users to hand-write a separate manifest. When MountPath is set it is
+// mounted into every container; when EnvFrom is set its keys are injected
+// as environment variables. Setting both does both.
+type ConfigMap struct {
+	Name      string            `yaml:"name"`
+	Data      map[string]string `yaml:"data,omitempty"`
+	MountPath string            `yaml:"mountPath,omitempty"`
+	EnvFrom   bool              `yaml:"envFrom,omitempty"`
+}
+
+// Secret is the Secret equivalent of ConfigMap.
+type Secret struct {
+	Name       string            `yaml:"name"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+	MountPath  string            `yaml:"mountPath,omitempty"`
+	EnvFrom    bool              `yaml:"envFrom,omitempty"`
+}
+
+func createConfigMap(app *App, cm *ConfigMap) *api_v1.ConfigMap {
+	return &api_v1.ConfigMap{
+		ObjectMeta: api_v1.ObjectMeta{
+			Name:   cm.Name,
+			Labels: app.Labels,
+		},
+		Data: cm.Data,
+	}
+}
+
+func createSecret(app *App, s *Secret) *api_v1.Secret {
+	secret := &api_v1.Secret{
+		ObjectMeta: api_v1.ObjectMeta{
+			Name:   s.Name,
+			Labels: app.Labels,
+		},
+		StringData: s.StringData,
+	}
+
+	for k, v := range s.Data {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[k] = []byte(v)
+	}
+
+	return secret
+}
+
+// addConfigMapsAndSecrets generates the ConfigMap/Secret objects for app and,
+// for every container, wires up the matching VolumeMounts/Volumes or
+// EnvFrom entries so the user doesn't have to hand-write either.
+func addConfigMapsAndSecrets(app *App) []runtime.Object {
+	var objects []runtime.Object
+
+	for i := range app.ConfigMaps {
+		cm := &app.ConfigMaps[i]
+		objects = append(objects, createConfigMap(app, cm))
+
+		if cm.MountPath != "" {
+			app.Volumes = append(app.Volumes, api_v1.Volume{
+				Name: cm.Name,
+				VolumeSource: api_v1.VolumeSource{
+					ConfigMap: &api_v1.ConfigMapVolumeSource{
+						LocalObjectReference: api_v1.LocalObjectReference{Name: cm.Name},
+					},
+				},
+			})
+			for ci := range app.Containers {
+				app.Containers[ci].VolumeMounts = append(app.Containers[ci].VolumeMounts, api_v1.VolumeMount{
+					Name:      cm.Name,
+					MountPath: cm.MountPath,
+				})
+			}
+		}
+
+		if cm.EnvFrom {
+			for ci := range app.Containers {
+				app.Containers[ci].EnvFrom = append(app.Containers[ci].EnvFrom, api_v1.EnvFromSource{
+					ConfigMapRef: &api_v1.ConfigMapEnvSource{
+						LocalObjectReference: api_v1.LocalObjectReference{Name: cm.Name},
+					},
+				})
+			}
+		}
+	}
+
+	for i := range app.Secrets {
+		s := &app.Secrets[i]
+		objects = append(objects, createSecret(app, s))
+
+		if s.MountPath != "" {
+			app.Volumes = append(app.Volumes, api_v1.Volume{
+				Name: s.Name,
+				VolumeSource: api_v1.VolumeSource{
+					Secret: &api_v1.SecretVolumeSource{SecretName: s.Name},
+				},
+			})
+			for ci := range app.Containers {
+				app.Containers[ci].VolumeMounts = append(app.Containers[ci].VolumeMounts, api_v1.VolumeMount{
+					Name:      s.Name,
+					MountPath: s.MountPath,
+				})
+			}
+		}
+
+		if s.EnvFrom {
+			for ci := range app.Containers {
+				app.Containers[ci].EnvFrom = append(app.Containers[ci].EnvFrom, api_v1.EnvFromSource{
+					SecretRef: &api_v1.SecretEnvSource{
+						LocalObjectReference: api_v1.LocalObjectReference{Name: s.Name},
+					},
+				})
+			}
+		}
+	}
+
+	return objects
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/pkg/api"
+	"k8s.io/client-go/pkg/runtime"
+)
+
+// Emitter is how a localWriter turns one already-marshalled object into
+// output. Convert picks an Emitter based on --out-dir/--out-file, falling
+// back to the stdout stream kedge has always produced.
+type Emitter interface {
+	Emit(o runtime.Object, data []byte) error
+	Close() error
+}
+
+type stdoutEmitter struct{}
+
+// NewStdoutEmitter prints each object as a "---"-separated YAML stream to
+// os.Stdout, same as kedge has always done.
+func NewStdoutEmitter() Emitter {
+	return &stdoutEmitter{}
+}
+
+func (*stdoutEmitter) Emit(o runtime.Object, data []byte) error {
+	if _, err := fmt.Fprintln(os.Stdout, "---"); err != nil {
+		return errors.Wrap(err, "could not print to STDOUT")
+	}
+	_, err := os.Stdout.Write(data)
+	return errors.Wrap(err, "could not write to STDOUT")
+}
+
+func (*stdoutEmitter) Close() error {
+	return nil
+}
+
+func objectFileName(o runtime.Object) (string, error) {
+	meta, err := api.ObjectMetaFor(o)
+	if err != nil {
+		return "", errors.Wrap(err, "could not get object metadata")
+	}
+	kind := strings.ToLower(o.GetObjectKind().GroupVersionKind().Kind)
+	return fmt.Sprintf("%s-%s.yaml", kind, meta.Name), nil
+}
+
+type dirEmitter struct {
+	dir string
+}
+
+// NewDirEmitter returns an Emitter that writes one file per object into
+// dir, named <kind>-<name>.yaml, for --out-dir.
+func NewDirEmitter(dir string) (Emitter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "could not create out-dir")
+	}
+	return &dirEmitter{dir: dir}, nil
+}
+
+func (e *dirEmitter) Emit(o runtime.Object, data []byte) error {
+	name, err := objectFileName(o)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(e.dir, name), data, 0644)
+	return errors.Wrap(err, "could not write object file")
+}
+
+func (*dirEmitter) Close() error {
+	return nil
+}
+
+type fileEmitter struct {
+	f *os.File
+}
+
+// NewFileEmitter returns an Emitter that appends every object, "---"-
+// separated, to a single combined file, for --out-file.
+func NewFileEmitter(path string) (Emitter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create out-file")
+	}
+	return &fileEmitter{f: f}, nil
+}
+
+func (e *fileEmitter) Emit(o runtime.Object, data []byte) error {
+	if _, err := fmt.Fprintln(e.f, "---"); err != nil {
+		return errors.Wrap(err, "could not write to out-file")
+	}
+	_, err := e.f.Write(data)
+	return errors.Wrap(err, "could not write to out-file")
+}
+
+func (e *fileEmitter) Close() error {
+	return e.f.Close()
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	api_v1 "k8s.io/client-go/pkg/api/v1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/pkg/runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Generate takes a Deployment plus its associated Service and
+// PersistentVolumeClaims (as produced by `kubectl get -o yaml` against a
+// live cluster) and collapses them back into the simplified App struct
+// written out by Convert. It is the mirror image of CreateK8sObjects.
+func Generate(objs []runtime.Object) (*App, error) {
+	var deployment *ext_v1beta1.Deployment
+	var svc *api_v1.Service
+	pvcs := map[string]*api_v1.PersistentVolumeClaim{}
+
+	for _, o := range objs {
+		switch t := o.(type) {
+		case *ext_v1beta1.Deployment:
+			deployment = t
+		case *api_v1.Service:
+			svc = t
+		case *api_v1.PersistentVolumeClaim:
+			pvcs[t.Name] = t
+		}
+	}
+
+	if deployment == nil {
+		return nil, errors.New("no Deployment found to generate an App from")
+	}
+
+	app := &App{
+		Name:     deployment.Name,
+		Replicas: deployment.Spec.Replicas,
+		PodSpec:  deployment.Spec.Template.Spec,
+	}
+
+	// drop label duplication: if the labels are exactly what getLabels
+	// would produce anyway, let CreateK8sObjects regenerate them
+	if len(deployment.Labels) != 1 || deployment.Labels["app"] != app.Name {
+		app.Labels = deployment.Labels
+	}
+
+	if svc != nil && svc.Spec.Type == api_v1.ServiceTypeLoadBalancer {
+		app.Expose = true
+	}
+
+	// reconstruct PersistentVolumes by pairing each container VolumeMount
+	// with its matching PVC's storage request, and drop the PVC-backed
+	// Volumes from the PodSpec since CreateK8sObjects re-derives them
+	var volumes []api_v1.Volume
+	for _, v := range app.PodSpec.Volumes {
+		if v.PersistentVolumeClaim == nil {
+			volumes = append(volumes, v)
+			continue
+		}
+
+		pvc, ok := pvcs[v.PersistentVolumeClaim.ClaimName]
+		if !ok {
+			volumes = append(volumes, v)
+			continue
+		}
+
+		size := pvc.Spec.Resources.Requests[api_v1.ResourceStorage]
+		app.PersistentVolumes = append(app.PersistentVolumes, Volume{
+			Volume: api_v1.Volume{Name: v.Name},
+			Size:   size.String(),
+		})
+	}
+	app.PodSpec.Volumes = volumes
+
+	return app, nil
+}
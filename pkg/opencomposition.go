@@ -3,8 +3,8 @@ package pkg
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ghodss/yaml"
@@ -18,24 +18,66 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	api_v1 "k8s.io/client-go/pkg/api/v1"
+	apps_v1beta1 "k8s.io/client-go/pkg/apis/apps/v1beta1"
 	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
 
 	// install api
 	_ "k8s.io/client-go/pkg/api/install"
+	_ "k8s.io/client-go/pkg/apis/apps/install"
 	_ "k8s.io/client-go/pkg/apis/extensions/install"
 )
 
+// the workload kinds CreateK8sObjects knows how to emit; deployment is the
+// default and keeps today's behaviour
+const (
+	WorkloadTypeDeployment  = "deployment"
+	WorkloadTypeStatefulSet = "statefulset"
+	WorkloadTypeDaemonSet   = "daemonset"
+)
+
 type Volume struct {
 	api_v1.Volume `yaml:",inline"`
 	Size          string
+	// AccessModes overrides the default ReadWriteOnce access mode, e.g.
+	// ["ReadWriteMany"] for volumes shared across pods.
+	AccessModes []string `yaml:"accessModes,omitempty"`
+	// DataSource lets a PVC restore from a VolumeSnapshot or clone from
+	// another PVC instead of starting out empty.
+	DataSource *VolumeDataSource `yaml:"dataSource,omitempty"`
+}
+
+// VolumeDataSource covers the subset of v1.TypedLocalObjectReference kedge
+// cares about: restoring from a CSI VolumeSnapshot, or cloning an existing
+// PVC. Exactly one of SnapshotName or SourcePVC should be set; if both are,
+// SnapshotName wins. StorageClassName is not part of the reference: it is
+// set as the claim's own storage class.
+type VolumeDataSource struct {
+	SnapshotName     string `yaml:"snapshotName,omitempty"`
+	SourcePVC        string `yaml:"sourcePVC,omitempty"`
+	StorageClassName string `yaml:"storageClassName,omitempty"`
+}
+
+// Ingress describes how an exposed App should be routed from outside the
+// cluster. It is only consulted when the App sets Expose to true.
+type Ingress struct {
+	Host        string            `yaml:"host,omitempty"`
+	Path        string            `yaml:"path,omitempty"`
+	TLSSecret   string            `yaml:"tlsSecret,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
 }
 
 type App struct {
-	Name              string            `yaml:"name"`
-	Replicas          *int32            `yaml:"replicas,omitempty"`
-	Expose            bool              `yaml:"expose,omitempty"`
+	Name     string `yaml:"name"`
+	Replicas *int32 `yaml:"replicas,omitempty"`
+	Expose   bool   `yaml:"expose,omitempty"`
+	// WorkloadType selects the controller CreateK8sObjects emits: one of
+	// "deployment" (default), "statefulset" or "daemonset".
+	WorkloadType      string            `yaml:"workloadType,omitempty"`
 	Labels            map[string]string `yaml:"labels,omitempty"`
 	PersistentVolumes []Volume          `yaml:"persistentVolumes,omitempty"`
+	Ingress           *Ingress          `yaml:"ingress,omitempty"`
+	ConfigMaps        []ConfigMap       `yaml:"configMaps,omitempty"`
+	Secrets           []Secret          `yaml:"secrets,omitempty"`
 	api_v1.PodSpec    `yaml:",inline"`
 }
 
@@ -47,9 +89,45 @@ func ReadFile(f string) ([]byte, error) {
 	return data, nil
 }
 
+func newWriter(v *viper.Viper) (Writer, error) {
+	if v.GetBool("apply") {
+		timeout := time.Duration(v.GetInt("timeout")) * time.Second
+		return NewApplyWriter(v.GetString("kubeconfig"), v.GetString("namespace"), timeout)
+	}
+
+	switch {
+	case v.GetString("out-dir") != "":
+		emitter, err := NewDirEmitter(v.GetString("out-dir"))
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalWriter(emitter), nil
+	case v.GetString("out-file") != "":
+		emitter, err := NewFileEmitter(v.GetString("out-file"))
+		if err != nil {
+			return nil, err
+		}
+		return NewLocalWriter(emitter), nil
+	default:
+		return NewLocalWriter(NewStdoutEmitter()), nil
+	}
+}
+
 func Convert(v *viper.Viper, cmd *cobra.Command) error {
 
-	for _, file := range strings.Split(v.GetStringSlice("files")[0], ",") {
+	writer, err := newWriter(v)
+	if err != nil {
+		return errors.Wrap(err, "could not set up writer")
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			log.Errorf("could not close writer: %s", err)
+		}
+	}()
+
+	namespace := v.GetString("namespace")
+
+	for _, file := range v.GetStringSlice("files") {
 		d, err := ReadFile(file)
 		if err != nil {
 			return errors.New(err.Error())
@@ -63,6 +141,9 @@ func Convert(v *viper.Viper, cmd *cobra.Command) error {
 		log.Debugf("file: %s, object unmrashalled: %#v", file, app)
 
 		runtimeObjects, err := CreateK8sObjects(&app)
+		if err != nil {
+			return errors.Wrap(err, "could not create k8s objects")
+		}
 
 		for _, runtimeObject := range runtimeObjects {
 			gvk, isUnversioned, err := api.Scheme.ObjectKind(runtimeObject)
@@ -75,25 +156,21 @@ func Convert(v *viper.Viper, cmd *cobra.Command) error {
 
 			runtimeObject.GetObjectKind().SetGroupVersionKind(gvk)
 
-			data, err := yaml.Marshal(runtimeObject)
-			if err != nil {
-				return errors.Wrap(err, "failed to marshal object")
-			}
-
-			writeObject := func(o runtime.Object, data []byte) error {
-				_, err := fmt.Fprintln(os.Stdout, "---")
+			if namespace != "" {
+				objMeta, err := api.ObjectMetaFor(runtimeObject)
 				if err != nil {
-					return errors.Wrap(err, "could not print to STDOUT")
+					return errors.Wrap(err, "could not stamp namespace")
 				}
-
-				_, err = os.Stdout.Write(data)
-				return errors.Wrap(err, "could not write to STDOUT")
+				objMeta.Namespace = namespace
 			}
+		}
 
-			err = writeObject(runtimeObject, data)
-			if err != nil {
-				return errors.Wrap(err, "failed to write object")
-			}
+		statuses, err := writer.Write(runtimeObjects)
+		for _, s := range statuses {
+			log.Infof("%s %q: %s %s", s.Kind, s.Name, s.Status, s.Message)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to write objects")
 		}
 	}
 
@@ -148,6 +225,59 @@ func createDeployment(app *App) *ext_v1beta1.Deployment {
 	}
 }
 
+func createDaemonSet(app *App) *ext_v1beta1.DaemonSet {
+	// bare minimum daemonset
+	return &ext_v1beta1.DaemonSet{
+		ObjectMeta: api_v1.ObjectMeta{
+			Name:   app.Name,
+			Labels: app.Labels,
+		},
+		Spec: ext_v1beta1.DaemonSetSpec{
+			Template: api_v1.PodTemplateSpec{
+				ObjectMeta: api_v1.ObjectMeta{
+					Name:   app.Name,
+					Labels: app.Labels,
+				},
+				Spec: api_v1.PodSpec(app.PodSpec),
+			},
+		},
+	}
+}
+
+// createStatefulSet builds a StatefulSet whose VolumeClaimTemplates come
+// from app.PersistentVolumes, instead of the pre-created standalone PVCs
+// used for Deployments. serviceName must point at a headless Service so
+// pods get stable DNS names.
+func createStatefulSet(app *App, serviceName string) (*apps_v1beta1.StatefulSet, error) {
+	var claimTemplates []api_v1.PersistentVolumeClaim
+	for i := range app.PersistentVolumes {
+		pvc, err := createPVC(&app.PersistentVolumes[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create volume claim template")
+		}
+		claimTemplates = append(claimTemplates, *pvc)
+	}
+
+	return &apps_v1beta1.StatefulSet{
+		ObjectMeta: api_v1.ObjectMeta{
+			Name:   app.Name,
+			Labels: app.Labels,
+		},
+		Spec: apps_v1beta1.StatefulSetSpec{
+			ServiceName: serviceName,
+			Replicas:    app.Replicas,
+			Template: api_v1.PodTemplateSpec{
+				ObjectMeta: api_v1.ObjectMeta{
+					Name:   app.Name,
+					Labels: app.Labels,
+				},
+				Spec: api_v1.PodSpec(app.PodSpec),
+			},
+			VolumeClaimTemplates: claimTemplates,
+		},
+	}, nil
+}
+
 func isVolumeDefined(app *App, name string) bool {
 	for _, v := range app.PersistentVolumes {
 		if name == v.Name {
@@ -166,6 +296,44 @@ func searchVolumeIndex(app *App, name string) int {
 	return -1
 }
 
+const snapshotAPIGroup = "snapshot.storage.k8s.io"
+
+func volumeAccessModes(v *Volume) []api_v1.PersistentVolumeAccessMode {
+	if len(v.AccessModes) == 0 {
+		return []api_v1.PersistentVolumeAccessMode{api_v1.ReadWriteOnce}
+	}
+
+	modes := make([]api_v1.PersistentVolumeAccessMode, len(v.AccessModes))
+	for i, m := range v.AccessModes {
+		modes[i] = api_v1.PersistentVolumeAccessMode(m)
+	}
+	return modes
+}
+
+func volumeDataSource(v *Volume) *api_v1.TypedLocalObjectReference {
+	if v.DataSource == nil {
+		return nil
+	}
+
+	if v.DataSource.SnapshotName != "" {
+		apiGroup := snapshotAPIGroup
+		return &api_v1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     "VolumeSnapshot",
+			Name:     v.DataSource.SnapshotName,
+		}
+	}
+
+	if v.DataSource.SourcePVC != "" {
+		return &api_v1.TypedLocalObjectReference{
+			Kind: "PersistentVolumeClaim",
+			Name: v.DataSource.SourcePVC,
+		}
+	}
+
+	return nil
+}
+
 func createPVC(v *Volume) (*api_v1.PersistentVolumeClaim, error) {
 	// create pvc
 	size, err := resource.ParseQuantity(v.Size)
@@ -173,7 +341,7 @@ func createPVC(v *Volume) (*api_v1.PersistentVolumeClaim, error) {
 		return nil, errors.Wrap(err, "could not read volume size")
 	}
 
-	return &api_v1.PersistentVolumeClaim{
+	pvc := &api_v1.PersistentVolumeClaim{
 		ObjectMeta: api_v1.ObjectMeta{
 			Name: v.Name,
 		},
@@ -183,27 +351,109 @@ func createPVC(v *Volume) (*api_v1.PersistentVolumeClaim, error) {
 					api_v1.ResourceStorage: size,
 				},
 			},
-			AccessModes: []api_v1.PersistentVolumeAccessMode{api_v1.ReadWriteOnce},
+			AccessModes: volumeAccessModes(v),
+			DataSource:  volumeDataSource(v),
 		},
-	}, nil
+	}
+
+	if v.DataSource != nil && v.DataSource.StorageClassName != "" {
+		pvc.Spec.StorageClassName = &v.DataSource.StorageClassName
+	}
+
+	return pvc, nil
+}
+
+func createIngress(app *App, svc *api_v1.Service) *ext_v1beta1.Ingress {
+	host := ""
+	path := "/"
+	if app.Ingress != nil {
+		host = app.Ingress.Host
+		if app.Ingress.Path != "" {
+			path = app.Ingress.Path
+		}
+	}
+
+	// a single host+path can only ever resolve to one backend, so with more
+	// than one exposed port each port needs its own path to stay routable
+	var paths []ext_v1beta1.HTTPIngressPath
+	for _, p := range svc.Spec.Ports {
+		portPath := path
+		if len(svc.Spec.Ports) > 1 {
+			portPath = strings.TrimSuffix(path, "/") + fmt.Sprintf("/port-%d", p.Port)
+		}
+
+		paths = append(paths, ext_v1beta1.HTTPIngressPath{
+			Path: portPath,
+			Backend: ext_v1beta1.IngressBackend{
+				ServiceName: svc.Name,
+				ServicePort: intstr.FromInt(int(p.Port)),
+			},
+		})
+	}
+
+	spec := ext_v1beta1.IngressSpec{
+		Rules: []ext_v1beta1.IngressRule{
+			{
+				// leaving Host empty makes this rule match any incoming
+				// host, which is the closest thing to a wildcard ingress
+				Host: host,
+				IngressRuleValue: ext_v1beta1.IngressRuleValue{
+					HTTP: &ext_v1beta1.HTTPIngressRuleValue{
+						Paths: paths,
+					},
+				},
+			},
+		},
+	}
+
+	if app.Ingress != nil && app.Ingress.TLSSecret != "" {
+		spec.TLS = []ext_v1beta1.IngressTLS{
+			{
+				Hosts:      []string{host},
+				SecretName: app.Ingress.TLSSecret,
+			},
+		}
+	}
+
+	var annotations map[string]string
+	if app.Ingress != nil {
+		annotations = app.Ingress.Annotations
+	}
+
+	return &ext_v1beta1.Ingress{
+		ObjectMeta: api_v1.ObjectMeta{
+			Name:        app.Name,
+			Labels:      app.Labels,
+			Annotations: annotations,
+		},
+		Spec: spec,
+	}
 }
 
 func CreateK8sObjects(app *App) ([]runtime.Object, error) {
 
 	var objects []runtime.Object
 	var svc *api_v1.Service
+	var ingress *ext_v1beta1.Ingress
 
 	if app.Labels == nil {
 		app.Labels = getLabels(app)
 	}
 
+	isStatefulSet := app.WorkloadType == WorkloadTypeStatefulSet
+	if isStatefulSet && app.Expose {
+		// a StatefulSet's governing Service must be headless (ClusterIP:
+		// None), which is mutually exclusive with the LoadBalancer type
+		// Expose asks for, so there is no single Service that satisfies both
+		return nil, errors.New("expose is not supported for statefulset workloads: a statefulset's governing service must be headless")
+	}
+
 	ports := allPorts(app)
 	if len(ports) > 0 {
 		// bare minimum service
 		svc = initService(app)
 		if app.Expose {
 			svc.Spec.Type = api_v1.ServiceTypeLoadBalancer
-			// TODO: create ingress
 		}
 		// update the service based on the ports given in the app
 		for _, p := range ports {
@@ -214,25 +464,34 @@ func CreateK8sObjects(app *App) ([]runtime.Object, error) {
 				TargetPort: intstr.FromInt(int(p.ContainerPort)),
 			})
 		}
+		if app.Expose {
+			ingress = createIngress(app, svc)
+		}
 	}
 
 	var pvcs []runtime.Object
 	for _, c := range app.Containers {
 		for _, vm := range c.VolumeMounts {
 
-			// User won't be giving this so we have to create it
-			// so that the pod spec is complete
-			podVolume := api_v1.Volume{
-				Name: vm.Name,
-				VolumeSource: api_v1.VolumeSource{
-					PersistentVolumeClaim: &api_v1.PersistentVolumeClaimVolumeSource{
-						ClaimName: vm.Name,
+			if !isStatefulSet {
+				// User won't be giving this so we have to create it
+				// so that the pod spec is complete. A StatefulSet instead
+				// gets a matching volume per pod from VolumeClaimTemplates.
+				podVolume := api_v1.Volume{
+					Name: vm.Name,
+					VolumeSource: api_v1.VolumeSource{
+						PersistentVolumeClaim: &api_v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: vm.Name,
+						},
 					},
-				},
+				}
+				app.Volumes = append(app.Volumes, podVolume)
 			}
-			app.Volumes = append(app.Volumes, podVolume)
 
 			if isVolumeDefined(app, vm.Name) {
+				if isStatefulSet {
+					continue
+				}
 				i := searchVolumeIndex(app, vm.Name)
 				pvc, err := createPVC(&app.PersistentVolumes[i])
 				if err != nil {
@@ -242,8 +501,11 @@ func CreateK8sObjects(app *App) ([]runtime.Object, error) {
 				continue
 			}
 
-			v := Volume{podVolume, "100Mi"}
+			v := Volume{Volume: api_v1.Volume{Name: vm.Name}, Size: "100Mi"}
 			app.PersistentVolumes = append(app.PersistentVolumes, v)
+			if isStatefulSet {
+				continue
+			}
 			pvc, err := createPVC(&v)
 			if err != nil {
 				return nil, errors.Wrap(err, "cannot create pvc")
@@ -257,17 +519,46 @@ func CreateK8sObjects(app *App) ([]runtime.Object, error) {
 		app.Containers[0].Name = app.Name
 	}
 
-	deployment := createDeployment(app)
+	configsAndSecrets := addConfigMapsAndSecrets(app)
+
+	var workload runtime.Object
+	switch app.WorkloadType {
+	case WorkloadTypeStatefulSet:
+		if svc == nil {
+			svc = initService(app)
+		}
+		// a StatefulSet needs a headless governing Service for stable pod DNS
+		svc.Spec.ClusterIP = api_v1.ClusterIPNone
+
+		statefulSet, err := createStatefulSet(app, svc.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create statefulset")
+		}
+		workload = statefulSet
+		log.Debugf("app: %s, statefulset: %s", app.Name, spew.Sprint(statefulSet))
+	case WorkloadTypeDaemonSet:
+		daemonSet := createDaemonSet(app)
+		workload = daemonSet
+		log.Debugf("app: %s, daemonset: %s", app.Name, spew.Sprint(daemonSet))
+	default:
+		deployment := createDeployment(app)
+		workload = deployment
+		log.Debugf("app: %s, deployment: %s", app.Name, spew.Sprint(deployment))
+	}
 
-	objects = append(objects, deployment)
-	log.Debugf("app: %s, deployment: %s", app.Name, spew.Sprint(deployment))
-	objects = append(objects, svc)
-	log.Debugf("app: %s, service: %s", app.Name, spew.Sprint(svc))
+	objects = append(objects, workload)
+	if svc != nil {
+		objects = append(objects, svc)
+		log.Debugf("app: %s, service: %s", app.Name, spew.Sprint(svc))
+	}
+	if ingress != nil {
+		objects = append(objects, ingress)
+		log.Debugf("app: %s, ingress: %s", app.Name, spew.Sprint(ingress))
+	}
 	objects = append(objects, pvcs...)
 	log.Debugf("app: %s, pvc: %s", app.Name, spew.Sprint(pvcs))
+	objects = append(objects, configsAndSecrets...)
+	log.Debugf("app: %s, configmaps/secrets: %s", app.Name, spew.Sprint(configsAndSecrets))
 
 	return objects, nil
 }
-
-// how to expose certain service using ingress
-//